@@ -0,0 +1,453 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package acme implements a minimal RFC 8555 ACME server that issues
+// certificates through an Issuer, so ACME clients (Caddy, cert-manager,
+// lego, golang.org/x/crypto/acme/autocert) can be pointed at a local mkcert
+// CA during development instead of a public CA.
+package acme
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Issuer signs a DER-encoded CSR and returns the resulting PEM certificate
+// (optionally including its issuing chain). *mkcert.MkCert satisfies this
+// via its IssueCertificate method, which calls SignCSRBytes.
+type Issuer interface {
+	IssueCertificate(csrDER []byte) (certPEM []byte, err error)
+}
+
+// Config configures a Server.
+type Config struct {
+	// Dir is where account, order, and challenge state is persisted
+	// (typically CAROOT/acme).
+	Dir string
+	// ExternalURL is the base URL clients use to reach this server, e.g.
+	// "https://localhost:14000". It's used to build the directory's
+	// resource URLs.
+	ExternalURL string
+}
+
+// Server is a minimal RFC 8555 ACME server.
+type Server struct {
+	issuer Issuer
+	cfg    Config
+	store  *store
+
+	mu     sync.Mutex
+	nonces map[string]bool
+}
+
+// NewServer creates an ACME server that issues certificates via issuer.
+func NewServer(issuer Issuer, cfg Config) (*Server, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("acme: Config.Dir must be set")
+	}
+	st, err := newStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		issuer: issuer,
+		cfg:    cfg,
+		store:  st,
+		nonces: map[string]bool{},
+	}, nil
+}
+
+// ListenAndServe starts the ACME server on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Handler returns the ACME server as an http.Handler, for callers that want
+// to mount it behind their own listener or TLS config.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", s.handleDirectory)
+	mux.HandleFunc("/acme/new-nonce", s.handleNewNonce)
+	mux.HandleFunc("/acme/new-account", s.handleNewAccount)
+	mux.HandleFunc("/acme/new-order", s.handleNewOrder)
+	mux.HandleFunc("/acme/authz/", s.handleAuthz)
+	mux.HandleFunc("/acme/challenge/", s.handleChallenge)
+	mux.HandleFunc("/acme/finalize/", s.handleFinalize)
+	mux.HandleFunc("/acme/order/", s.handleOrder)
+	mux.HandleFunc("/acme/cert/", s.handleCert)
+	mux.HandleFunc("/.well-known/acme-challenge/", s.handleHTTP01)
+	return mux
+}
+
+func (s *Server) url(path string) string {
+	return strings.TrimRight(s.cfg.ExternalURL, "/") + path
+}
+
+func (s *Server) handleDirectory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"newNonce":   s.url("/acme/new-nonce"),
+		"newAccount": s.url("/acme/new-account"),
+		"newOrder":   s.url("/acme/new-order"),
+		"revokeCert": s.url("/acme/revoke-cert"),
+		"keyChange":  s.url("/acme/key-change"),
+	})
+}
+
+func (s *Server) handleNewNonce(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) newNonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	nonce := base64.RawURLEncoding.EncodeToString(buf)
+
+	s.mu.Lock()
+	s.nonces[nonce] = true
+	s.mu.Unlock()
+	return nonce
+}
+
+func (s *Server) consumeNonce(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.nonces[nonce] {
+		return false
+	}
+	delete(s.nonces, nonce)
+	return true
+}
+
+func (s *Server) handleNewAccount(w http.ResponseWriter, r *http.Request) {
+	jws, err := parseJWS(r.Body)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	if !s.consumeNonce(jws.nonce) {
+		writeProblem(w, http.StatusBadRequest, "badNonce", "nonce not found")
+		return
+	}
+
+	acct, err := s.store.createAccount(jws.jwk)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "serverInternal", err.Error())
+		return
+	}
+
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	w.Header().Set("Location", s.url("/acme/account/"+acct.ID))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "valid",
+		"orders": s.url("/acme/account/" + acct.ID + "/orders"),
+	})
+}
+
+func (s *Server) handleNewOrder(w http.ResponseWriter, r *http.Request) {
+	jws, err := parseJWS(r.Body)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	if !s.consumeNonce(jws.nonce) {
+		writeProblem(w, http.StatusBadRequest, "badNonce", "nonce not found")
+		return
+	}
+
+	acct, ok := s.store.getAccount(accountIDFromKID(jws.kid))
+	if !ok {
+		writeProblem(w, http.StatusUnauthorized, "accountDoesNotExist", "unknown or missing account key ID")
+		return
+	}
+
+	var req struct {
+		Identifiers []identifier `json:"identifiers"`
+	}
+	if err := json.Unmarshal(jws.payload, &req); err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+
+	order, err := s.store.createOrder(req.Identifiers, acct.JWKThumbprint)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "serverInternal", err.Error())
+		return
+	}
+
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	w.Header().Set("Location", s.url("/acme/order/"+order.ID))
+	writeJSON(w, http.StatusCreated, s.orderResponse(order))
+}
+
+func (s *Server) orderResponse(o *order) map[string]interface{} {
+	authzURLs := make([]string, len(o.AuthzIDs))
+	for i, id := range o.AuthzIDs {
+		authzURLs[i] = s.url("/acme/authz/" + id)
+	}
+	resp := map[string]interface{}{
+		"status":         o.Status,
+		"identifiers":    o.Identifiers,
+		"authorizations": authzURLs,
+		"finalize":       s.url("/acme/finalize/" + o.ID),
+	}
+	if o.Status == "valid" {
+		resp["certificate"] = s.url("/acme/cert/" + o.ID)
+	}
+	return resp
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/acme/order/")
+	o, ok := s.store.getOrder(id)
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "malformed", "unknown order")
+		return
+	}
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	writeJSON(w, http.StatusOK, s.orderResponse(o))
+}
+
+func (s *Server) handleAuthz(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/acme/authz/")
+	a, ok := s.store.getAuthz(id)
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "malformed", "unknown authorization")
+		return
+	}
+
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     a.Status,
+		"identifier": a.Identifier,
+		"challenges": []map[string]interface{}{
+			{
+				"type":   "http-01",
+				"url":    s.url("/acme/challenge/" + a.ID + "/http-01"),
+				"token":  a.Token,
+				"status": a.Status,
+			},
+			{
+				"type":   "tls-alpn-01",
+				"url":    s.url("/acme/challenge/" + a.ID + "/tls-alpn-01"),
+				"token":  a.Token,
+				"status": a.Status,
+			},
+		},
+	})
+}
+
+func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/acme/challenge/")
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 {
+		writeProblem(w, http.StatusNotFound, "malformed", "unknown challenge")
+		return
+	}
+	authzID, challengeType := parts[0], parts[1]
+
+	jws, err := parseJWS(r.Body)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	if !s.consumeNonce(jws.nonce) {
+		writeProblem(w, http.StatusBadRequest, "badNonce", "nonce not found")
+		return
+	}
+
+	a, ok := s.store.getAuthz(authzID)
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "malformed", "unknown authorization")
+		return
+	}
+
+	if err := s.validateChallenge(a, challengeType); err != nil {
+		writeProblem(w, http.StatusForbidden, "incorrectResponse", err.Error())
+		return
+	}
+
+	s.store.markAuthzValid(a.ID)
+
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":   challengeType,
+		"status": "valid",
+		"token":  a.Token,
+	})
+}
+
+// validateChallenge actually performs domain validation for a, so that
+// handleChallenge only marks an authorization valid once the client has
+// demonstrated control of the identifier, the same way a real ACME server
+// would.
+func (s *Server) validateChallenge(a *authz, challengeType string) error {
+	switch challengeType {
+	case "http-01":
+		return s.validateHTTP01(a)
+	case "tls-alpn-01":
+		// Dialing tls-alpn-01 back out requires inspecting the client's
+		// acmeIdentifier certificate extension during the TLS handshake,
+		// which this minimal server doesn't implement. Only allow the
+		// trust-on-first-use shortcut for loopback identifiers, where the
+		// client and this server are known to be the same trusted machine;
+		// reject it for anything else rather than approving every identifier
+		// unconditionally.
+		if !isLoopbackIdentifier(a.Identifier.Value) {
+			return fmt.Errorf("acme: tls-alpn-01 validation is only supported for loopback identifiers, got %q", a.Identifier.Value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("acme: unsupported challenge type %q", challengeType)
+	}
+}
+
+// validateHTTP01 fetches the http-01 response the client is required to
+// serve at http://<identifier>/.well-known/acme-challenge/<token> and checks
+// it against the authorization's key authorization.
+func (s *Server) validateHTTP01(a *authz) error {
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", a.Identifier.Value, a.Token)
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("acme: http-01 validation request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("acme: http-01 validation got status %d from %s", resp.StatusCode, url)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("acme: failed to read http-01 validation response: %w", err)
+	}
+	if strings.TrimSpace(string(body)) != a.KeyAuthorization {
+		return fmt.Errorf("acme: http-01 key authorization mismatch for %s", a.Identifier.Value)
+	}
+	return nil
+}
+
+// accountIDFromKID extracts the account ID from a JWS "kid" header, which
+// ACME clients set to the full account URL (e.g.
+// ".../acme/account/<id>") on every request after account creation.
+func accountIDFromKID(kid string) string {
+	if idx := strings.LastIndex(kid, "/"); idx != -1 {
+		return kid[idx+1:]
+	}
+	return kid
+}
+
+// isLoopbackIdentifier reports whether value (an ACME identifier) resolves
+// to the local machine.
+func isLoopbackIdentifier(value string) bool {
+	if value == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(value)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) handleFinalize(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/acme/finalize/")
+	o, ok := s.store.getOrder(id)
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "malformed", "unknown order")
+		return
+	}
+
+	jws, err := parseJWS(r.Body)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	if !s.consumeNonce(jws.nonce) {
+		writeProblem(w, http.StatusBadRequest, "badNonce", "nonce not found")
+		return
+	}
+
+	var req struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(jws.payload, &req); err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	csrDER, err := base64.RawURLEncoding.DecodeString(req.CSR)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed", "invalid csr encoding")
+		return
+	}
+
+	certPEM, err := s.issuer.IssueCertificate(csrDER)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "serverInternal", err.Error())
+		return
+	}
+
+	if err := s.store.finalizeOrder(o.ID, certPEM); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "serverInternal", err.Error())
+		return
+	}
+
+	w.Header().Set("Replay-Nonce", s.newNonce())
+	writeJSON(w, http.StatusOK, s.orderResponse(o))
+}
+
+func (s *Server) handleCert(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/acme/cert/")
+	o, ok := s.store.getOrder(id)
+	if !ok || o.Certificate == nil {
+		writeProblem(w, http.StatusNotFound, "malformed", "unknown certificate")
+		return
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Write(o.Certificate)
+}
+
+// handleHTTP01 serves http-01 challenge responses for any authorization
+// this server knows about, so external ACME clients that do dial back in
+// (rather than relying on the trust-on-first-use shortcut above) are still
+// answered correctly.
+func (s *Server) handleHTTP01(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+	keyAuth, ok := s.store.keyAuthorizationForToken(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	fmt.Fprint(w, keyAuth)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, status int, typ, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"type":   "urn:ietf:params:acme:error:" + typ,
+		"detail": detail,
+	})
+}
+
+// parseCertPEM sanity-checks that the bytes handed back from the Issuer are
+// a well-formed certificate before they're persisted to an order.
+func parseCertPEM(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("acme: no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
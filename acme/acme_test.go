@@ -0,0 +1,76 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acme
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type noopIssuer struct{}
+
+func (noopIssuer) IssueCertificate(csrDER []byte) ([]byte, error) { return nil, nil }
+
+func TestValidateHTTP01(t *testing.T) {
+	s, err := NewServer(noopIssuer{}, Config{Dir: t.TempDir(), ExternalURL: "https://localhost:14000"})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	key := &jwk{Kty: "RSA", N: "not-a-real-modulus", E: "AQAB"}
+	acct, err := s.store.createAccount(key)
+	if err != nil {
+		t.Fatalf("createAccount: %v", err)
+	}
+	thumb, err := key.thumbprint()
+	if err != nil {
+		t.Fatalf("thumbprint: %v", err)
+	}
+
+	o, err := s.store.createOrder([]identifier{{Type: "dns", Value: strings.TrimPrefix(ts.URL, "http://")}}, acct.JWKThumbprint)
+	if err != nil {
+		t.Fatalf("createOrder: %v", err)
+	}
+	a, ok := s.store.getAuthz(o.AuthzIDs[0])
+	if !ok {
+		t.Fatal("createOrder did not create an authorization")
+	}
+
+	// The key authorization must follow RFC 8555 §8.1 (token + "." +
+	// thumbprint), not just echo back whatever this server happens to have
+	// stored, so a client computing it independently from its own account
+	// key would actually succeed against this server.
+	if want := a.Token + "." + thumb; a.KeyAuthorization != want {
+		t.Fatalf("authz.KeyAuthorization = %q, want %q", a.KeyAuthorization, want)
+	}
+
+	if err := s.validateHTTP01(a); err != nil {
+		t.Errorf("validateHTTP01 with the correct key authorization: %v", err)
+	}
+
+	wrong := &authz{Identifier: a.Identifier, Token: a.Token, KeyAuthorization: "not-the-right-value"}
+	if err := s.validateHTTP01(wrong); err == nil {
+		t.Error("validateHTTP01 accepted a mismatched key authorization")
+	}
+}
+
+func TestIsLoopbackIdentifier(t *testing.T) {
+	cases := map[string]bool{
+		"localhost":   true,
+		"127.0.0.1":   true,
+		"::1":         true,
+		"example.com": false,
+		"192.168.1.1": false,
+	}
+	for value, want := range cases {
+		if got := isLoopbackIdentifier(value); got != want {
+			t.Errorf("isLoopbackIdentifier(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func signFlattenedJWS(t *testing.T, alg string, key *jwk, sign func(signingInput []byte) []byte) *flattenedJWS {
+	t.Helper()
+
+	header, err := json.Marshal(jwsHeader{Alg: alg, Nonce: "test-nonce", URL: "https://example.test/new-account", JWK: mustMarshalJWK(t, key)})
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	payload := []byte(`{"termsOfServiceAgreed":true}`)
+
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	sig := sign([]byte(protected + "." + encodedPayload))
+
+	return &flattenedJWS{
+		Protected: protected,
+		Payload:   encodedPayload,
+		Signature: base64.RawURLEncoding.EncodeToString(sig),
+	}
+}
+
+func mustMarshalJWK(t *testing.T, key *jwk) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(key)
+	if err != nil {
+		t.Fatalf("marshal jwk: %v", err)
+	}
+	return raw
+}
+
+func TestParseJWSVerifiesES256AccountKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key := &jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(priv.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(priv.Y.Bytes()),
+	}
+
+	flat := signFlattenedJWS(t, "ES256", key, func(signingInput []byte) []byte {
+		sum := sha256.Sum256(signingInput)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, sum[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign: %v", err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	})
+
+	body, err := json.Marshal(flat)
+	if err != nil {
+		t.Fatalf("marshal flattened JWS: %v", err)
+	}
+
+	parsed, err := parseJWS(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("parseJWS (ES256): %v", err)
+	}
+	if parsed.jwk == nil || parsed.jwk.Kty != "EC" {
+		t.Fatalf("parsed.jwk = %+v, want an EC key", parsed.jwk)
+	}
+}
+
+func TestParseJWSVerifiesRS256AccountKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key := &jwk{
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(priv.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.PublicKey.E)).Bytes()),
+	}
+
+	flat := signFlattenedJWS(t, "RS256", key, func(signingInput []byte) []byte {
+		sum := sha256.Sum256(signingInput)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatalf("rsa.SignPKCS1v15: %v", err)
+		}
+		return sig
+	})
+
+	body, err := json.Marshal(flat)
+	if err != nil {
+		t.Fatalf("marshal flattened JWS: %v", err)
+	}
+
+	if _, err := parseJWS(bytes.NewReader(body)); err != nil {
+		t.Fatalf("parseJWS (RS256): %v", err)
+	}
+}
+
+func TestParseJWSRejectsBadSignature(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key := &jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(priv.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(priv.Y.Bytes()),
+	}
+
+	// Sign with a different key than the one embedded in the JWS header.
+	otherPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	flat := signFlattenedJWS(t, "ES256", key, func(signingInput []byte) []byte {
+		sum := sha256.Sum256(signingInput)
+		r, s, err := ecdsa.Sign(rand.Reader, otherPriv, sum[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign: %v", err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	})
+
+	body, err := json.Marshal(flat)
+	if err != nil {
+		t.Fatalf("marshal flattened JWS: %v", err)
+	}
+
+	if _, err := parseJWS(bytes.NewReader(body)); err == nil {
+		t.Fatal("parseJWS accepted a signature from the wrong key")
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acme
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeAccount struct {
+	ID            string `json:"id"`
+	JWKThumbprint string `json:"jwkThumbprint"`
+}
+
+type authz struct {
+	ID               string     `json:"id"`
+	OrderID          string     `json:"orderID"`
+	Identifier       identifier `json:"identifier"`
+	Token            string     `json:"token"`
+	KeyAuthorization string     `json:"keyAuthorization"`
+	Status           string     `json:"status"` // pending, valid
+}
+
+type order struct {
+	ID          string       `json:"id"`
+	Identifiers []identifier `json:"identifiers"`
+	AuthzIDs    []string     `json:"authzIDs"`
+	Status      string       `json:"status"` // pending, ready, valid
+	Certificate []byte       `json:"certificate,omitempty"`
+}
+
+// store persists ACME account, order, and authorization state under
+// CAROOT/acme, keeping an in-memory index for fast lookups.
+type store struct {
+	dir string
+
+	mu              sync.Mutex
+	accountsByThumb map[string]*acmeAccount
+	accountsByID    map[string]*acmeAccount
+	orders          map[string]*order
+	authzs          map[string]*authz
+	tokenToAuthzID  map[string]string
+}
+
+func newStore(dir string) (*store, error) {
+	for _, sub := range []string{"accounts", "orders", "authz"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("acme: failed to create %s directory: %w", sub, err)
+		}
+	}
+	s := &store{
+		dir:             dir,
+		accountsByThumb: map[string]*acmeAccount{},
+		accountsByID:    map[string]*acmeAccount{},
+		orders:          map[string]*order{},
+		authzs:          map[string]*authz{},
+		tokenToAuthzID:  map[string]string{},
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads back the accounts, orders, and authorizations a previous server
+// process persisted under dir, so restarting the server doesn't forget
+// in-flight (or completed) ACME state.
+func (s *store) load() error {
+	accounts, err := loadJSONDir[acmeAccount](s.dir, "accounts")
+	if err != nil {
+		return err
+	}
+	for _, acct := range accounts {
+		s.accountsByThumb[acct.JWKThumbprint] = acct
+		s.accountsByID[acct.ID] = acct
+	}
+
+	orders, err := loadJSONDir[order](s.dir, "orders")
+	if err != nil {
+		return err
+	}
+	for _, o := range orders {
+		s.orders[o.ID] = o
+	}
+
+	authzs, err := loadJSONDir[authz](s.dir, "authz")
+	if err != nil {
+		return err
+	}
+	for _, a := range authzs {
+		s.authzs[a.ID] = a
+		s.tokenToAuthzID[a.Token] = a.ID
+	}
+
+	return nil
+}
+
+// loadJSONDir parses every *.json file directly inside dir/sub as a T,
+// skipping files that fail to parse so a single corrupt entry doesn't block
+// server startup.
+func loadJSONDir[T any](dir, sub string) ([]*T, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, sub))
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to list %s directory: %w", sub, err)
+	}
+
+	var values []*T
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, sub, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("acme: failed to read %s/%s: %w", sub, entry.Name(), err)
+		}
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			continue
+		}
+		values = append(values, &v)
+	}
+	return values, nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s *store) createAccount(key *jwk) (*acmeAccount, error) {
+	if key == nil {
+		return nil, fmt.Errorf("acme: new-account request must carry a jwk")
+	}
+	thumb, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if acct, ok := s.accountsByThumb[thumb]; ok {
+		return acct, nil
+	}
+
+	acct := &acmeAccount{ID: randomID(), JWKThumbprint: thumb}
+	s.accountsByThumb[thumb] = acct
+	s.accountsByID[acct.ID] = acct
+	return acct, s.saveJSON(filepath.Join("accounts", acct.ID+".json"), acct)
+}
+
+func (s *store) getAccount(id string) (*acmeAccount, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, ok := s.accountsByID[id]
+	return acct, ok
+}
+
+// createOrder creates an order for identifiers on behalf of the account
+// identified by accountThumbprint, binding each authorization's key
+// authorization to that account's JWK thumbprint per RFC 8555 §8.1 so a
+// challenge response can only be validated when it was computed from the
+// requesting account's own key.
+func (s *store) createOrder(identifiers []identifier, accountThumbprint string) (*order, error) {
+	if len(identifiers) == 0 {
+		return nil, fmt.Errorf("acme: order must specify at least one identifier")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := &order{ID: randomID(), Identifiers: identifiers, Status: "pending"}
+	for _, id := range identifiers {
+		token := randomID()
+		a := &authz{
+			ID:               randomID(),
+			OrderID:          o.ID,
+			Identifier:       id,
+			Token:            token,
+			KeyAuthorization: token + "." + accountThumbprint,
+			Status:           "pending",
+		}
+		s.authzs[a.ID] = a
+		s.tokenToAuthzID[a.Token] = a.ID
+		o.AuthzIDs = append(o.AuthzIDs, a.ID)
+		if err := s.saveJSON(filepath.Join("authz", a.ID+".json"), a); err != nil {
+			return nil, err
+		}
+	}
+	s.orders[o.ID] = o
+	return o, s.saveJSON(filepath.Join("orders", o.ID+".json"), o)
+}
+
+func (s *store) getOrder(id string) (*order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[id]
+	return o, ok
+}
+
+func (s *store) getAuthz(id string) (*authz, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authzs[id]
+	return a, ok
+}
+
+func (s *store) markAuthzValid(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.authzs[id]
+	if !ok {
+		return
+	}
+	a.Status = "valid"
+	s.saveJSON(filepath.Join("authz", a.ID+".json"), a)
+
+	o, ok := s.orders[a.OrderID]
+	if !ok {
+		return
+	}
+	allValid := true
+	for _, authzID := range o.AuthzIDs {
+		if az := s.authzs[authzID]; az.Status != "valid" {
+			allValid = false
+			break
+		}
+	}
+	if allValid {
+		o.Status = "ready"
+		s.saveJSON(filepath.Join("orders", o.ID+".json"), o)
+	}
+}
+
+func (s *store) finalizeOrder(id string, certPEM []byte) error {
+	if _, err := parseCertPEM(certPEM); err != nil {
+		return fmt.Errorf("acme: issuer returned an invalid certificate: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return fmt.Errorf("acme: unknown order %q", id)
+	}
+	o.Certificate = certPEM
+	o.Status = "valid"
+	return s.saveJSON(filepath.Join("orders", o.ID+".json"), o)
+}
+
+func (s *store) keyAuthorizationForToken(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authzID, ok := s.tokenToAuthzID[token]
+	if !ok {
+		return "", false
+	}
+	return s.authzs[authzID].KeyAuthorization, true
+}
+
+func (s *store) saveJSON(relPath string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, relPath), data, 0644)
+}
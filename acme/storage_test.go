@@ -0,0 +1,54 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acme
+
+import "testing"
+
+func TestStorePersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	s1, err := newStore(dir)
+	if err != nil {
+		t.Fatalf("newStore: %v", err)
+	}
+	key := &jwk{Kty: "RSA", N: "not-a-real-modulus", E: "AQAB"}
+	acct, err := s1.createAccount(key)
+	if err != nil {
+		t.Fatalf("createAccount: %v", err)
+	}
+	o, err := s1.createOrder([]identifier{{Type: "dns", Value: "example.com"}}, acct.JWKThumbprint)
+	if err != nil {
+		t.Fatalf("createOrder: %v", err)
+	}
+
+	s2, err := newStore(dir)
+	if err != nil {
+		t.Fatalf("newStore (reopen): %v", err)
+	}
+
+	reloadedOrder, ok := s2.getOrder(o.ID)
+	if !ok {
+		t.Fatal("order did not survive a restart")
+	}
+	if len(reloadedOrder.AuthzIDs) != 1 {
+		t.Fatalf("reloadedOrder.AuthzIDs = %v, want 1 entry", reloadedOrder.AuthzIDs)
+	}
+
+	if _, ok := s2.getAuthz(reloadedOrder.AuthzIDs[0]); !ok {
+		t.Fatal("authorization did not survive a restart")
+	}
+
+	reloadedAcct, err := s2.createAccount(key)
+	if err != nil {
+		t.Fatalf("createAccount (reopen): %v", err)
+	}
+	if reloadedAcct.ID != acct.ID {
+		t.Errorf("createAccount after restart returned a new account (%q), want the existing one (%q)", reloadedAcct.ID, acct.ID)
+	}
+
+	if _, ok := s2.getAccount(acct.ID); !ok {
+		t.Error("getAccount did not find the account by ID after a restart")
+	}
+}
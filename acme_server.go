@@ -0,0 +1,40 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/yarlson/mkcert/acme"
+)
+
+// ACMEConfig configures ServeACME.
+type ACMEConfig = acme.Config
+
+// IssueCertificate signs a DER-encoded CSR and returns the resulting PEM
+// certificate, satisfying acme.Issuer so ServeACME can issue from this CA.
+func (m *MkCert) IssueCertificate(csrDER []byte) ([]byte, error) {
+	return m.SignCSRBytes(csrDER, nil)
+}
+
+// ServeACME starts an RFC 8555 ACME server on addr that issues certificates
+// from this CA, so ACME clients (Caddy, cert-manager, lego, autocert) can be
+// pointed at it in place of a public CA during development. Account and
+// order state is kept under cfg.Dir, which defaults to CAROOT/acme.
+func (m *MkCert) ServeACME(addr string, cfg ACMEConfig) error {
+	if m.m.caKey == nil {
+		return fmt.Errorf("can't serve ACME because the CA key (rootCA-key.pem) is missing")
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(m.m.CAROOT, "acme")
+	}
+
+	srv, err := acme.NewServer(m, cfg)
+	if err != nil {
+		return err
+	}
+	return srv.ListenAndServe(addr)
+}
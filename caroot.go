@@ -0,0 +1,61 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	rootName    = "rootCA.pem"
+	rootKeyName = "rootCA-key.pem"
+)
+
+// mkcert holds the local CA's certificate, key, and trust-store integration.
+// The exported MkCert wrapper builds on it to add a pluggable KeyManager and
+// optional intermediate-CA chaining.
+type mkcert struct {
+	CAROOT string
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+
+	// ignoreCheckFailure is set once Install has already attempted (and
+	// possibly failed) installing into the system trust store, so a later
+	// check in the same run doesn't report it as still missing.
+	ignoreCheckFailure bool
+}
+
+// getCAROOT returns the default CA root directory: $CAROOT if set, or an
+// OS-appropriate per-user data directory otherwise. It returns "" if neither
+// can be determined.
+func getCAROOT() string {
+	if env := os.Getenv("CAROOT"); env != "" {
+		return env
+	}
+
+	var dir string
+	switch {
+	case runtime.GOOS == "windows":
+		dir = os.Getenv("LocalAppData")
+	case runtime.GOOS == "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, "Library", "Application Support")
+		}
+	case os.Getenv("XDG_DATA_HOME") != "":
+		dir = os.Getenv("XDG_DATA_HOME")
+	default:
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "mkcert")
+}
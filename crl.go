@@ -0,0 +1,232 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const indexName = "ca.index"
+
+// IssuedCert is a record of a certificate issued by this CA, kept in the
+// CAROOT/ca.index file so it can later be looked up for revocation.
+type IssuedCert struct {
+	Serial           *big.Int  `json:"serial"`
+	Subject          string    `json:"subject"`
+	NotAfter         time.Time `json:"notAfter"`
+	SHA256           string    `json:"sha256"`
+	Issuer           string    `json:"issuer"` // "root", or an intermediate CA's name
+	Revoked          bool      `json:"revoked"`
+	RevokedAt        time.Time `json:"revokedAt,omitempty"`
+	RevocationReason int       `json:"revocationReason,omitempty"`
+}
+
+// rootIssuer identifies the root CA in IssuedCert.Issuer and index entries
+// predating the Issuer field.
+const rootIssuer = "root"
+
+// issuerName identifies which CA m signs with: the root, or the named
+// intermediate it was returned for by CreateIntermediateCA.
+func (m *MkCert) issuerName() string {
+	if m.intermediate != nil {
+		return m.intermediate.Name
+	}
+	return rootIssuer
+}
+
+// indexPath returns the path to this CA's certificate index.
+func (m *MkCert) indexPath() string {
+	return filepath.Join(m.m.CAROOT, indexName)
+}
+
+func (m *MkCert) loadIndex() ([]IssuedCert, error) {
+	data, err := os.ReadFile(m.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA index: %w", err)
+	}
+	var index []IssuedCert
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the CA index: %w", err)
+	}
+	return index, nil
+}
+
+func (m *MkCert) saveIndex(index []IssuedCert) error {
+	data, err := json.MarshalIndent(index, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode the CA index: %w", err)
+	}
+	if err := os.WriteFile(m.indexPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write the CA index: %w", err)
+	}
+	return nil
+}
+
+// recordIssued appends a newly issued certificate to the CA index.
+func (m *MkCert) recordIssued(cert *x509.Certificate) error {
+	index, err := m.loadIndex()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(cert.Raw)
+	index = append(index, IssuedCert{
+		Serial:   cert.SerialNumber,
+		Subject:  cert.Subject.String(),
+		NotAfter: cert.NotAfter,
+		SHA256:   fmt.Sprintf("%x", sum),
+		Issuer:   m.issuerName(),
+	})
+	return m.saveIndex(index)
+}
+
+// ListIssued returns every certificate this CA has issued, in issuance order.
+func (m *MkCert) ListIssued() ([]IssuedCert, error) {
+	return m.loadIndex()
+}
+
+// Revoke marks a previously issued certificate as revoked. serialOrPath is
+// either the certificate's decimal serial number or a path to its PEM file.
+// reason is an RFC 5280 CRL reason code (e.g. 1 for keyCompromise).
+func (m *MkCert) Revoke(serialOrPath string, reason int) error {
+	serial, err := resolveSerial(serialOrPath)
+	if err != nil {
+		return err
+	}
+
+	index, err := m.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	for i := range index {
+		if index[i].Serial.Cmp(serial) == 0 {
+			index[i].Revoked = true
+			index[i].RevokedAt = time.Now()
+			index[i].RevocationReason = reason
+			return m.saveIndex(index)
+		}
+	}
+	return fmt.Errorf("no issued certificate found with serial %s", serial)
+}
+
+func resolveSerial(serialOrPath string) (*big.Int, error) {
+	if serial, ok := new(big.Int).SetString(serialOrPath, 10); ok {
+		return serial, nil
+	}
+
+	data, err := os.ReadFile(serialOrPath)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a decimal serial number nor a readable certificate file: %w", serialOrPath, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse PEM certificate at %q", serialOrPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate at %q: %w", serialOrPath, err)
+	}
+	return cert.SerialNumber, nil
+}
+
+// GenerateCRL builds a certificate revocation list covering every revoked
+// certificate m's own CA (the root, or an intermediate if m was returned by
+// CreateIntermediateCA) has issued, signs it with that CA's key, and writes
+// it PEM-encoded to out. Call GenerateCRL on the *MkCert returned by
+// CreateIntermediateCA to get that intermediate's own CRL. nextUpdate
+// controls how long clients may cache the CRL before refetching it.
+func (m *MkCert) GenerateCRL(out string, nextUpdate time.Duration) error {
+	der, err := m.buildCRL(nextUpdate)
+	if err != nil {
+		return err
+	}
+	crlPEM := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	if err := os.WriteFile(out, crlPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write the CRL: %w", err)
+	}
+	return nil
+}
+
+// buildCRL signs a CRL with m's own issuer (the root, or the intermediate m
+// was returned for by CreateIntermediateCA), covering only the certificates
+// that issuer issued. This keeps the CRL's issuer consistent with the certs
+// it lists: a chain validator rejects a CRL whose issuer doesn't match the
+// certificate's actual issuer.
+func (m *MkCert) buildCRL(nextUpdate time.Duration) ([]byte, error) {
+	signerCert, signerKey := m.m.caCert, m.m.caKey
+	if m.intermediate != nil {
+		signerCert, signerKey = m.intermediate.Cert, m.intermediate.Key
+	}
+	if signerKey == nil {
+		return nil, fmt.Errorf("can't generate a CRL because the CA key (rootCA-key.pem) is missing")
+	}
+
+	index, err := m.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := m.issuerName()
+	var revoked []x509.RevocationListEntry
+	for _, issued := range index {
+		entryIssuer := issued.Issuer
+		if entryIssuer == "" {
+			entryIssuer = rootIssuer // index entries predating the Issuer field were all root-issued
+		}
+		if !issued.Revoked || entryIssuer != issuer {
+			continue
+		}
+		revoked = append(revoked, x509.RevocationListEntry{
+			SerialNumber:   issued.Serial,
+			RevocationTime: issued.RevokedAt,
+			ReasonCode:     issued.RevocationReason,
+		})
+	}
+
+	tpl := &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().Unix()),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(nextUpdate),
+		RevokedCertificateEntries: revoked,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, tpl, signerCert, signerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the CRL: %w", err)
+	}
+	return der, nil
+}
+
+// ServeCRL starts an HTTP server on addr that serves the CA's current CRL,
+// regenerated on every request, at "/". This lets clients configured with a
+// CRLDistributionPoints URL fetch revocation status during development.
+func (m *MkCert) ServeCRL(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		der, err := m.buildCRL(7 * 24 * time.Hour)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Header().Set("Content-Length", strconv.Itoa(len(der)))
+		w.Write(der)
+	})
+	return http.ListenAndServe(addr, mux)
+}
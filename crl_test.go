@@ -0,0 +1,38 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRecordIssuedTagsTheIssuer(t *testing.T) {
+	root := &MkCert{m: &mkcert{CAROOT: t.TempDir()}}
+	intermediate := &MkCert{m: root.m, intermediate: &IntermediateCA{Name: "staging"}}
+
+	if err := root.recordIssued(&x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now()}); err != nil {
+		t.Fatalf("recordIssued (root): %v", err)
+	}
+	if err := intermediate.recordIssued(&x509.Certificate{SerialNumber: big.NewInt(2), NotAfter: time.Now()}); err != nil {
+		t.Fatalf("recordIssued (intermediate): %v", err)
+	}
+
+	index, err := root.loadIndex()
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+	if len(index) != 2 {
+		t.Fatalf("len(index) = %d, want 2", len(index))
+	}
+	if index[0].Issuer != rootIssuer {
+		t.Errorf("index[0].Issuer = %q, want %q", index[0].Issuer, rootIssuer)
+	}
+	if index[1].Issuer != "staging" {
+		t.Errorf("index[1].Issuer = %q, want %q", index[1].Issuer, "staging")
+	}
+}
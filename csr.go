@@ -0,0 +1,171 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// oidExtKeyUsage is the X.509 extended key usage extension OID (2.5.29.37).
+// x509.CertificateRequest has no typed ExtKeyUsage field, so MakeCSR embeds
+// the extension directly via ExtraExtensions.
+var oidExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+var (
+	oidExtKeyUsageServerAuth = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}
+	oidExtKeyUsageClientAuth = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}
+)
+
+// CSROptions represents options for generating a certificate signing
+// request with MakeCSR.
+type CSROptions struct {
+	ECDSA   bool   // Use ECDSA instead of RSA
+	Client  bool   // Request a certificate for client authentication
+	Subject string // Common name for the CSR subject; defaults to the first hostname
+}
+
+// MakeCSR generates a private key and a PKCS#10 certificate signing request
+// for hostnames, without touching the CA. It's meant to be embedded in
+// provisioning/enrollment services that hand the CSR to SignCSRBytes (or an
+// external CA) rather than calling MakeCert directly.
+func (m *MkCert) MakeCSR(hostnames []string, opts *CSROptions) (csrPEM, keyPEM []byte, err error) {
+	if len(hostnames) == 0 {
+		return nil, nil, fmt.Errorf("no hostnames specified")
+	}
+	if opts == nil {
+		opts = &CSROptions{}
+	}
+
+	key, err := m.keyManager.GenerateKey(keyAlgoFor(opts.ECDSA))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate the CSR key: %w", err)
+	}
+
+	subject := opts.Subject
+	if subject == "" {
+		subject = hostnames[0]
+	}
+
+	usage := oidExtKeyUsageServerAuth
+	if opts.Client {
+		usage = oidExtKeyUsageClientAuth
+	}
+	usageValue, err := asn1.Marshal([]asn1.ObjectIdentifier{usage})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode the key usage extension: %w", err)
+	}
+
+	tpl := &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: subject},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtKeyUsage, Value: usageValue},
+		},
+	}
+
+	tpl.DNSNames, tpl.IPAddresses, tpl.EmailAddresses, tpl.URIs = classifySANs(hostnames)
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, tpl, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the CSR: %w", err)
+	}
+	csrPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal the CSR key: %w", err)
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return csrPEM, keyPEM, nil
+}
+
+// SignOptions represents options for signing a CSR with SignCSRBytes.
+type SignOptions struct {
+	Client                bool          // Sign for client rather than server authentication
+	ValidityPeriod        time.Duration // Defaults to the same lifetime MakeCert uses
+	CRLDistributionPoints []string      // URLs embedded in the certificate's CRL distribution point extension
+}
+
+// SignCSRBytes signs a PEM or DER-encoded CSR entirely in memory, without any
+// filesystem round trip, and returns the resulting PEM certificate. If m was
+// returned by CreateIntermediateCA, the certificate is signed by that
+// intermediate; otherwise it's signed directly by the root CA.
+func (m *MkCert) SignCSRBytes(csr []byte, opts *SignOptions) ([]byte, error) {
+	if m.m.caKey == nil {
+		return nil, fmt.Errorf("can't sign certificates because the CA key (rootCA-key.pem) is missing")
+	}
+	if opts == nil {
+		opts = &SignOptions{}
+	}
+	validity := opts.ValidityPeriod
+	if validity <= 0 {
+		validity = defaultLeafValidity
+	}
+
+	der := csr
+	if block, _ := pem.Decode(csr); block != nil {
+		der = block.Bytes
+	}
+	req, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the CSR: %w", err)
+	}
+	if err := req.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("the CSR's signature doesn't verify: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the leaf serial number: %w", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               req.Subject,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		DNSNames:              req.DNSNames,
+		IPAddresses:           req.IPAddresses,
+		EmailAddresses:        req.EmailAddresses,
+		URIs:                  req.URIs,
+		CRLDistributionPoints: opts.CRLDistributionPoints,
+	}
+	if opts.Client {
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	} else {
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	signerCert, signerKey := m.m.caCert, m.m.caKey
+	if m.intermediate != nil {
+		signerCert, signerKey = m.intermediate.Cert, m.intermediate.Key
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, tpl, signerCert, req.PublicKey, signerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the signed certificate: %w", err)
+	}
+	if err := m.recordIssued(cert); err != nil {
+		return nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	if m.intermediate != nil {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: m.intermediate.Cert.Raw})...)
+	}
+	return certPEM, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestMakeCSRAndSignCSRBytes(t *testing.T) {
+	dir := t.TempDir()
+	mc := &MkCert{m: &mkcert{CAROOT: dir}, keyManager: newFileKeyManager(dir), logger: stdLogger{}}
+	if err := mc.loadOrCreateRootCA(); err != nil {
+		t.Fatalf("loadOrCreateRootCA: %v", err)
+	}
+
+	csrPEM, keyPEM, err := mc.MakeCSR([]string{"example.com", "127.0.0.1"}, nil)
+	if err != nil {
+		t.Fatalf("MakeCSR: %v", err)
+	}
+	if block, _ := pem.Decode(keyPEM); block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("MakeCSR returned a malformed key PEM")
+	}
+
+	certPEM, err := mc.SignCSRBytes(csrPEM, nil)
+	if err != nil {
+		t.Fatalf("SignCSRBytes: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("SignCSRBytes returned a malformed certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "example.com" {
+		t.Errorf("cert.DNSNames = %v, want [example.com]", cert.DNSNames)
+	}
+	if len(cert.IPAddresses) != 1 || cert.IPAddresses[0].String() != "127.0.0.1" {
+		t.Errorf("cert.IPAddresses = %v, want [127.0.0.1]", cert.IPAddresses)
+	}
+	if err := cert.CheckSignatureFrom(mc.m.caCert); err != nil {
+		t.Errorf("signed certificate doesn't chain to the root CA: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	intermediatesDirName  = "intermediates"
+	intermediateCertName  = "intermediateCA.pem"
+	intermediateKeyName   = "intermediateCA-key.pem"
+	defaultIntermediateCA = 5 * 365 * 24 * time.Hour
+)
+
+// IntermediateOptions represents options for creating an intermediate CA.
+type IntermediateOptions struct {
+	ECDSA          bool          // Use ECDSA instead of RSA for the intermediate key
+	MaxPathLen     int           // Maximum number of CAs that may appear below this one in the chain
+	ValidityPeriod time.Duration // How long the intermediate CA certificate is valid for; defaults to 5 years
+}
+
+// IntermediateCA represents an intermediate certificate authority signed by
+// the root CA.
+type IntermediateCA struct {
+	Name string
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// CreateIntermediateCA generates an intermediate CA signed by the root CA and
+// stores it under CAROOT/intermediates/<name>/. The returned *MkCert behaves
+// like m, except that MakeCert and SignCSRBytes issue leaf certificates
+// chained to this intermediate rather than directly to the root (MakeCert
+// also includes the intermediate in the output bundle and PKCS#12 chain),
+// and GenerateCRL covers only the certificates this intermediate issued.
+func (m *MkCert) CreateIntermediateCA(name string, opts *IntermediateOptions) (*MkCert, error) {
+	if m.m.caKey == nil {
+		return nil, fmt.Errorf("can't create an intermediate CA because the CA key (rootCA-key.pem) is missing")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("intermediate CA name must not be empty")
+	}
+
+	if opts == nil {
+		opts = &IntermediateOptions{}
+	}
+	validity := opts.ValidityPeriod
+	if validity <= 0 {
+		validity = defaultIntermediateCA
+	}
+
+	key, err := m.keyManager.GenerateAndStoreKey(intermediateKeyID(name), keyAlgoFor(opts.ECDSA))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the intermediate CA key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the intermediate CA serial number: %w", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization:       []string{"mkcert development CA"},
+			OrganizationalUnit: []string{name},
+			CommonName:         name,
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            opts.MaxPathLen,
+		MaxPathLenZero:        opts.MaxPathLen == 0,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, m.m.caCert, key.Public(), m.m.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the intermediate CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the intermediate CA certificate: %w", err)
+	}
+
+	dir := filepath.Join(m.m.CAROOT, intermediatesDirName, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the intermediate CA directory: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, intermediateCertName), certPEM, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save the intermediate CA certificate: %w", err)
+	}
+
+	return &MkCert{
+		m:          m.m,
+		keyManager: m.keyManager,
+		logger:     m.logger,
+		intermediate: &IntermediateCA{
+			Name: name,
+			Cert: cert,
+			Key:  key,
+		},
+	}, nil
+}
+
+// intermediateKeyID is the KeyManager id under which an intermediate CA's key
+// is stored; the default file-backed manager resolves it to
+// CAROOT/intermediates/<name>/intermediateCA-key.pem.
+func intermediateKeyID(name string) string {
+	return filepath.Join(intermediatesDirName, name, "intermediateCA")
+}
+
+// IntermediateFiles returns the paths to an intermediate CA's certificate and
+// key files.
+func (m *MkCert) IntermediateFiles(name string) (certPath, keyPath string) {
+	dir := filepath.Join(m.m.CAROOT, intermediatesDirName, name)
+	return filepath.Join(dir, intermediateCertName), filepath.Join(dir, intermediateKeyName)
+}
+
+const defaultLeafValidity = 825 * 24 * time.Hour // matches the CA/Browser Forum's maximum leaf lifetime
+
+// makeCertWithIntermediate issues a leaf certificate signed by m.intermediate
+// and writes out a PEM bundle (leaf + intermediate) and, if requested, a
+// PKCS#12 file whose CA chain includes the intermediate.
+func (m *MkCert) makeCertWithIntermediate(hostnames []string, options *CertOptions) (*IssueResult, error) {
+	return m.issueLeaf(m.intermediate.Cert, m.intermediate.Key, []*x509.Certificate{m.intermediate.Cert}, hostnames, options)
+}
+
+// issueLeaf generates a leaf key through m.keyManager, signs a certificate
+// for hostnames with signerCert/signerKey, and writes out a PEM bundle (the
+// leaf followed by chain, if any) and, if requested, a PKCS#12 file whose CA
+// chain is chain. chain is empty when signing directly with the root.
+func (m *MkCert) issueLeaf(signerCert *x509.Certificate, signerKey crypto.Signer, chain []*x509.Certificate, hostnames []string, options *CertOptions) (*IssueResult, error) {
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("no hostnames specified")
+	}
+
+	ecdsaKey := false
+	client := false
+	pkcs12Out := false
+	certFile, keyFile, p12File := "", "", ""
+	if options != nil {
+		ecdsaKey = options.ECDSA
+		client = options.Client
+		pkcs12Out = options.PKCS12
+		certFile, keyFile, p12File = options.CertFile, options.KeyFile, options.P12File
+	}
+
+	key, err := m.keyManager.GenerateKey(keyAlgoFor(ecdsaKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the leaf key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the leaf serial number: %w", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{Organization: []string{"mkcert development certificate"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(defaultLeafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	if client {
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	} else {
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	if options != nil {
+		tpl.CRLDistributionPoints = options.CRLDistributionPoints
+	}
+
+	tpl.DNSNames, tpl.IPAddresses, tpl.EmailAddresses, tpl.URIs = classifySANs(hostnames)
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signerCert, key.Public(), signerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the leaf certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the leaf certificate: %w", err)
+	}
+
+	base := strings.Join(hostnames, "+")
+	if certFile == "" {
+		certFile = base + ".pem"
+	}
+	if keyFile == "" {
+		keyFile = base + "-key.pem"
+	}
+	if p12File == "" {
+		p12File = base + ".p12"
+	}
+
+	certOut := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	for _, c := range chain {
+		certOut = append(certOut, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	if err := os.WriteFile(certFile, certOut, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save the leaf certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the leaf key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save the leaf key: %w", err)
+	}
+
+	if pkcs12Out {
+		p12, err := pkcs12.Encode(rand.Reader, key, cert, chain, pkcs12.DefaultPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build the PKCS#12 bundle: %w", err)
+		}
+		if err := os.WriteFile(p12File, p12, 0644); err != nil {
+			return nil, fmt.Errorf("failed to save the PKCS#12 bundle: %w", err)
+		}
+	}
+
+	if err := m.recordIssued(cert); err != nil {
+		return nil, err
+	}
+
+	result := &IssueResult{
+		CertPath: certFile,
+		KeyPath:  keyFile,
+		Serial:   cert.SerialNumber,
+		NotAfter: cert.NotAfter,
+		Chain:    append([]*x509.Certificate{cert}, chain...),
+	}
+	if pkcs12Out {
+		result.P12Path = p12File
+	}
+
+	m.logger.Infof("Created a new certificate valid for the following names: %v", hostnames)
+	return result, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+func TestCreateIntermediateCAChainsLeavesThroughTheIntermediate(t *testing.T) {
+	dir := t.TempDir()
+	mc := &MkCert{m: &mkcert{CAROOT: dir}, keyManager: newFileKeyManager(dir), logger: stdLogger{}}
+	if err := mc.loadOrCreateRootCA(); err != nil {
+		t.Fatalf("loadOrCreateRootCA: %v", err)
+	}
+
+	intermediate, err := mc.CreateIntermediateCA("staging", nil)
+	if err != nil {
+		t.Fatalf("CreateIntermediateCA: %v", err)
+	}
+	intermediateCert := intermediate.intermediate.Cert
+	if err := intermediateCert.CheckSignatureFrom(mc.m.caCert); err != nil {
+		t.Fatalf("intermediate certificate doesn't chain to the root CA: %v", err)
+	}
+
+	workdir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(workdir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+
+	result, err := intermediate.MakeCert([]string{"example.com"}, &CertOptions{PKCS12: true})
+	if err != nil {
+		t.Fatalf("MakeCert: %v", err)
+	}
+
+	certOut, err := os.ReadFile(result.CertPath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", result.CertPath, err)
+	}
+	leafBlock, rest := pem.Decode(certOut)
+	if leafBlock == nil {
+		t.Fatal("cert bundle doesn't start with a PEM certificate")
+	}
+	leaf, err := x509.ParseCertificate(leafBlock.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate(leaf): %v", err)
+	}
+	if err := leaf.CheckSignatureFrom(intermediateCert); err != nil {
+		t.Errorf("leaf certificate doesn't chain to the intermediate CA: %v", err)
+	}
+
+	intermediateBlock, _ := pem.Decode(rest)
+	if intermediateBlock == nil {
+		t.Fatal("cert bundle doesn't include the intermediate certificate")
+	}
+	bundledIntermediate, err := x509.ParseCertificate(intermediateBlock.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate(intermediate): %v", err)
+	}
+	if !bundledIntermediate.Equal(intermediateCert) {
+		t.Error("bundled intermediate certificate doesn't match the one CreateIntermediateCA returned")
+	}
+
+	p12Out, err := os.ReadFile(result.P12Path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", result.P12Path, err)
+	}
+	_, _, caCerts, err := pkcs12.DecodeChain(p12Out, pkcs12.DefaultPassword)
+	if err != nil {
+		t.Fatalf("pkcs12.DecodeChain: %v", err)
+	}
+	if len(caCerts) != 1 || !caCerts[0].Equal(intermediateCert) {
+		t.Errorf("PKCS#12 CA chain = %v, want just the intermediate CA", caCerts)
+	}
+
+	intermediateCertPath, _ := mc.IntermediateFiles("staging")
+	if _, err := os.Stat(intermediateCertPath); err != nil {
+		t.Errorf("intermediate certificate wasn't saved under CAROOT: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// KeyAlgo selects the algorithm a KeyManager should use when generating a
+// new key.
+type KeyAlgo int
+
+const (
+	KeyAlgoRSA KeyAlgo = iota
+	KeyAlgoECDSA
+)
+
+// KeyManager generates and persists the private keys MkCert works with. The
+// default implementation keeps keys as PEM files under CAROOT, matching
+// mkcert's historical behavior; alternative implementations can back keys
+// with an HSM or platform keystore instead.
+type KeyManager interface {
+	// GenerateKey creates a new private key using algo. Depending on the
+	// implementation, the key may or may not already be persisted.
+	GenerateKey(algo KeyAlgo) (crypto.Signer, error)
+	// GenerateAndStoreKey creates a new private key for id using algo and
+	// persists it in one step, so a later LoadKey(id) returns it. Callers
+	// that need a long-lived, retrievable key (a root or intermediate CA
+	// key) must use this instead of GenerateKey+StoreKey: implementations
+	// that generate directly on a token, where the key material never
+	// leaves it, can only bind the key to id at generation time.
+	GenerateAndStoreKey(id string, algo KeyAlgo) (crypto.Signer, error)
+	// LoadKey returns a previously stored key identified by id.
+	LoadKey(id string) (crypto.Signer, error)
+	// StoreKey persists s so it can later be retrieved with LoadKey(id).
+	// Implementations that can't import externally-generated key material
+	// (e.g. a PKCS#11 token) may reject this.
+	StoreKey(id string, s crypto.Signer) error
+}
+
+// fileKeyManager is the default KeyManager: keys are generated in memory and
+// stored as PKCS#8 PEM files under dir, named "<id>-key.pem".
+type fileKeyManager struct {
+	dir string
+}
+
+func newFileKeyManager(dir string) *fileKeyManager {
+	return &fileKeyManager{dir: dir}
+}
+
+func (f *fileKeyManager) GenerateKey(algo KeyAlgo) (crypto.Signer, error) {
+	if algo == KeyAlgoECDSA {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return rsa.GenerateKey(rand.Reader, 3072)
+}
+
+func (f *fileKeyManager) GenerateAndStoreKey(id string, algo KeyAlgo) (crypto.Signer, error) {
+	key, err := f.GenerateKey(algo)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.StoreKey(id, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (f *fileKeyManager) LoadKey(id string) (crypto.Signer, error) {
+	data, err := os.ReadFile(f.keyPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %q: %w", id, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM key %q", id)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %q: %w", id, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key %q is not a signing key", id)
+	}
+	return signer, nil
+}
+
+func (f *fileKeyManager) StoreKey(id string, s crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key %q: %w", id, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.keyPath(id)), 0755); err != nil {
+		return fmt.Errorf("failed to create the key directory: %w", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(f.keyPath(id), pemBytes, 0600); err != nil {
+		return fmt.Errorf("failed to store key %q: %w", id, err)
+	}
+	return nil
+}
+
+func (f *fileKeyManager) keyPath(id string) string {
+	return filepath.Join(f.dir, id+"-key.pem")
+}
+
+func keyAlgoFor(ecdsaKey bool) KeyAlgo {
+	if ecdsaKey {
+		return KeyAlgoECDSA
+	}
+	return KeyAlgoRSA
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build pkcs11
+
+package main
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+
+	"github.com/ThalesGroup/crypto11"
+)
+
+// PKCS11Config configures a PKCS11KeyManager.
+type PKCS11Config = crypto11.Config
+
+// PKCS11KeyManager is a KeyManager backed by a PKCS#11 token, so the CA (and
+// any intermediate) private key never touches disk in plaintext. Build with
+// the "pkcs11" tag and a PKCS#11 library (e.g. SoftHSM2, a YubiKey, or a
+// cloud HSM's PKCS#11 module) available at cfg.Path.
+type PKCS11KeyManager struct {
+	ctx *crypto11.Context
+}
+
+// NewPKCS11KeyManager opens a session against the PKCS#11 token described by
+// cfg.
+func NewPKCS11KeyManager(cfg *PKCS11Config) (*PKCS11KeyManager, error) {
+	ctx, err := crypto11.Configure(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the PKCS#11 token: %w", err)
+	}
+	return &PKCS11KeyManager{ctx: ctx}, nil
+}
+
+// keyIDFor derives the CKA_ID a key generated for id is stored under, so
+// GenerateAndStoreKey and LoadKey always agree on where to find it.
+func keyIDFor(id string) []byte {
+	sum := sha1.Sum([]byte(id))
+	return sum[:]
+}
+
+// GenerateKey generates a key pair directly on the token under a random,
+// unrecoverable id. It's only suitable for keys the caller never needs to
+// retrieve again by id (e.g. a one-off leaf key); use GenerateAndStoreKey
+// for a root or intermediate CA key, which must be findable by LoadKey
+// later.
+func (p *PKCS11KeyManager) GenerateKey(algo KeyAlgo) (crypto.Signer, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate a key id: %w", err)
+	}
+	return p.generateKeyPair(id, algo)
+}
+
+// GenerateAndStoreKey generates a key pair directly on the token, deriving
+// its CKA_ID from id so a later LoadKey(id) call finds the same key pair.
+func (p *PKCS11KeyManager) GenerateAndStoreKey(id string, algo KeyAlgo) (crypto.Signer, error) {
+	return p.generateKeyPair(keyIDFor(id), algo)
+}
+
+func (p *PKCS11KeyManager) generateKeyPair(keyID []byte, algo KeyAlgo) (crypto.Signer, error) {
+	if algo == KeyAlgoECDSA {
+		signer, err := p.ctx.GenerateECDSAKeyPair(keyID, elliptic.P256())
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate an ECDSA key on the token: %w", err)
+		}
+		return signer, nil
+	}
+
+	signer, err := p.ctx.GenerateRSAKeyPair(keyID, 3072)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate an RSA key on the token: %w", err)
+	}
+	return signer, nil
+}
+
+// LoadKey looks up a key pair previously created with GenerateAndStoreKey by
+// deriving the same CKA_ID from id.
+func (p *PKCS11KeyManager) LoadKey(id string) (crypto.Signer, error) {
+	signer, err := p.ctx.FindKeyPair(keyIDFor(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find key %q on the token: %w", id, err)
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("no key found on the token with id %q", id)
+	}
+	return signer, nil
+}
+
+// StoreKey is unsupported: PKCS#11 key pairs are generated and persisted on
+// the token itself by GenerateAndStoreKey, and importing externally-generated
+// key material onto a token is intentionally unsupported here.
+func (p *PKCS11KeyManager) StoreKey(id string, s crypto.Signer) error {
+	return fmt.Errorf("PKCS11KeyManager does not support importing keys; generate them on the token with GenerateAndStoreKey")
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestFileKeyManagerRoundTrip(t *testing.T) {
+	km := newFileKeyManager(t.TempDir())
+
+	key, err := km.GenerateKey(KeyAlgoECDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("GenerateKey(KeyAlgoECDSA) returned %T, want *ecdsa.PrivateKey", key)
+	}
+
+	if err := km.StoreKey("leaf", key); err != nil {
+		t.Fatalf("StoreKey: %v", err)
+	}
+
+	loaded, err := km.LoadKey("leaf")
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	if !loaded.Public().(*ecdsa.PublicKey).Equal(key.Public()) {
+		t.Error("LoadKey returned a different key than was stored")
+	}
+}
+
+func TestFileKeyManagerGenerateAndStoreKey(t *testing.T) {
+	km := newFileKeyManager(t.TempDir())
+
+	key, err := km.GenerateAndStoreKey("rootCA", KeyAlgoECDSA)
+	if err != nil {
+		t.Fatalf("GenerateAndStoreKey: %v", err)
+	}
+
+	loaded, err := km.LoadKey("rootCA")
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	if !loaded.Public().(*ecdsa.PublicKey).Equal(key.Public()) {
+		t.Error("LoadKey returned a different key than GenerateAndStoreKey produced")
+	}
+}
+
+func TestFileKeyManagerGenerateRSA(t *testing.T) {
+	km := newFileKeyManager(t.TempDir())
+
+	key, err := km.GenerateKey(KeyAlgoRSA)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		t.Fatalf("GenerateKey(KeyAlgoRSA) returned %T, want *rsa.PrivateKey", key)
+	}
+}
+
+func TestFileKeyManagerLoadMissingKey(t *testing.T) {
+	km := newFileKeyManager(t.TempDir())
+	if _, err := km.LoadKey("does-not-exist"); err == nil {
+		t.Fatal("LoadKey of a missing key should fail")
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "fmt"
+
+// Logger receives diagnostic output from MkCert. Implementations embedding
+// MkCert in a daemon or test suite can route this wherever they like instead
+// of it going straight to stdout.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// WithLogger makes New and NewWithCARoot send diagnostic output to l instead
+// of the default stdout logger.
+func WithLogger(l Logger) Option {
+	return func(m *MkCert) { m.logger = l }
+}
+
+// stdLogger is the default Logger: it preserves mkcert's historical
+// behavior of printing info/warning/error messages straight to stdout, and
+// discards Debugf output.
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...interface{}) {}
+
+func (stdLogger) Infof(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func (stdLogger) Warnf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func (stdLogger) Errorf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
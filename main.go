@@ -0,0 +1,76 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var (
+		installFlag   = flag.Bool("install", false, "install the local CA in the system trust store")
+		uninstallFlag = flag.Bool("uninstall", false, "uninstall the local CA from the system trust store")
+		csrFlag       = flag.String("csr", "", "sign this CSR file instead of generating a new certificate")
+		certFile      = flag.String("cert-file", "", "certificate output file")
+		keyFile       = flag.String("key-file", "", "key output file")
+		p12File       = flag.String("p12-file", "", "PKCS#12 output file")
+		client        = flag.Bool("client", false, "generate a certificate for client authentication")
+		ecdsaKey      = flag.Bool("ecdsa", false, "use ECDSA instead of RSA for the generated key")
+		pkcs12Out     = flag.Bool("pkcs12", false, "also generate a PKCS#12 file, for legacy software")
+	)
+	flag.Parse()
+
+	m, err := New()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mkcert:", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case *installFlag:
+		if _, err := m.Install(); err != nil {
+			fmt.Fprintln(os.Stderr, "mkcert:", err)
+			os.Exit(1)
+		}
+		return
+	case *uninstallFlag:
+		if err := m.Uninstall(); err != nil {
+			fmt.Fprintln(os.Stderr, "mkcert:", err)
+			os.Exit(1)
+		}
+		return
+	case *csrFlag != "":
+		if err := m.MakeCertFromCSR(*csrFlag, *certFile); err != nil {
+			fmt.Fprintln(os.Stderr, "mkcert:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	hostnames := flag.Args()
+	if len(hostnames) == 0 {
+		certPath, keyPath := m.CAFiles()
+		fmt.Printf("The local CA is at %q and %q.\n", certPath, keyPath)
+		fmt.Println("Usage: mkcert [-install] [-cert-file FILE] [-key-file FILE] [-client] [-ecdsa] [-pkcs12] HOSTNAME...")
+		return
+	}
+
+	result, err := m.MakeCert(hostnames, &CertOptions{
+		ECDSA:    *ecdsaKey,
+		Client:   *client,
+		PKCS12:   *pkcs12Out,
+		CertFile: *certFile,
+		KeyFile:  *keyFile,
+		P12File:  *p12File,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mkcert:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created a new certificate valid for the following names: %v\n", hostnames)
+	fmt.Printf("The certificate is at %q and the key at %q.\n", result.CertPath, result.KeyPath)
+}
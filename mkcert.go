@@ -8,60 +8,116 @@ package main
 import (
 	"crypto/x509"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // MkCert provides an API for creating locally-trusted development certificates.
 type MkCert struct {
 	// underlying implementation
 	m *mkcert
+
+	// intermediate is the signing CA used by MakeCert, if this MkCert was
+	// returned by CreateIntermediateCA. When nil, MakeCert signs directly
+	// with the root CA.
+	intermediate *IntermediateCA
+
+	// keyManager generates and stores the private keys MkCert works with.
+	// It defaults to a file-backed implementation; pass WithKeyManager to
+	// New or NewWithCARoot to keep keys in an HSM or platform keystore
+	// instead.
+	keyManager KeyManager
+
+	// logger receives diagnostic output. It defaults to stdLogger; pass
+	// WithLogger to New or NewWithCARoot to route it elsewhere.
+	logger Logger
 }
 
 // CertOptions represents options for certificate creation.
 type CertOptions struct {
-	ECDSA    bool   // Use ECDSA instead of RSA
-	Client   bool   // Generate a certificate for client authentication
-	PKCS12   bool   // Generate a PKCS#12 file
-	CertFile string // Custom certificate file path
-	KeyFile  string // Custom key file path
-	P12File  string // Custom PKCS#12 file path
+	ECDSA                 bool     // Use ECDSA instead of RSA
+	Client                bool     // Generate a certificate for client authentication
+	PKCS12                bool     // Generate a PKCS#12 file
+	CertFile              string   // Custom certificate file path
+	KeyFile               string   // Custom key file path
+	P12File               string   // Custom PKCS#12 file path
+	CRLDistributionPoints []string // URLs embedded in the certificate's CRL distribution point extension
+}
+
+// Option configures an MkCert instance. See WithKeyManager.
+type Option func(*MkCert)
+
+// WithKeyManager makes New and NewWithCARoot use km to generate, load, and
+// store private keys instead of the default file-backed implementation. This
+// lets the CA (and intermediate) key live in an HSM or platform keystore
+// instead of as a plaintext PEM file under CAROOT.
+func WithKeyManager(km KeyManager) Option {
+	return func(m *MkCert) { m.keyManager = km }
 }
 
 // New creates a new MkCert instance.
-func New() (*MkCert, error) {
+func New(opts ...Option) (*MkCert, error) {
 	caRoot := getCAROOT()
 	if caRoot == "" {
 		return nil, fmt.Errorf("failed to find the default CA location, set one as the CAROOT env var")
 	}
+	return newMkCert(caRoot, opts)
+}
+
+// NewWithCARoot creates a new MkCert instance with a custom CA root directory.
+func NewWithCARoot(caRoot string, opts ...Option) (*MkCert, error) {
+	return newMkCert(caRoot, opts)
+}
 
+// newMkCert sets up the MkCert wrapper and its KeyManager, then loads the
+// root CA certificate, generating a new root CA through the KeyManager if
+// none exists yet under caRoot.
+func newMkCert(caRoot string, opts []Option) (*MkCert, error) {
 	if err := os.MkdirAll(caRoot, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create the CAROOT: %w", err)
 	}
 
-	m := &mkcert{CAROOT: caRoot}
-	m.loadCA()
-
-	return &MkCert{m: m}, nil
-}
+	mc := &MkCert{m: &mkcert{CAROOT: caRoot}}
+	for _, opt := range opts {
+		opt(mc)
+	}
+	if mc.keyManager == nil {
+		mc.keyManager = newFileKeyManager(caRoot)
+	}
+	if mc.logger == nil {
+		mc.logger = stdLogger{}
+	}
 
-// NewWithCARoot creates a new MkCert instance with a custom CA root directory.
-func NewWithCARoot(caRoot string) (*MkCert, error) {
-	if err := os.MkdirAll(caRoot, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create the CAROOT: %w", err)
+	if err := mc.loadOrCreateRootCA(); err != nil {
+		return nil, err
 	}
 
-	m := &mkcert{CAROOT: caRoot}
-	m.loadCA()
+	return mc, nil
+}
 
-	return &MkCert{m: m}, nil
+// InstallReport describes the outcome of Install.
+type InstallReport struct {
+	SystemInstalled bool     // The CA was installed in the OS trust store
+	NSSInstalled    bool     // The CA was installed in the NSS (Firefox/Chrome on Linux) trust store
+	JavaInstalled   bool     // The CA was installed in Java's trust store
+	NSSBrowsers     string   // Browsers NSSInstalled covers, e.g. "Firefox and/or Chrome/Chromium"
+	Warnings        []string // Non-fatal issues, e.g. a trust store being present but not installable
+	Errors          []error  // Errors encountered while installing into a trust store
 }
 
 // Install installs the local CA in the system trust store.
-func (m *MkCert) Install() error {
+func (m *MkCert) Install() (*InstallReport, error) {
+	report := &InstallReport{}
+
 	if storeEnabled("system") && !m.m.checkPlatform() {
 		if m.m.installPlatform() {
-			fmt.Println("The local CA is now installed in the system trust store!")
+			report.SystemInstalled = true
+			m.logger.Infof("The local CA is now installed in the system trust store!")
+		} else {
+			report.Warnings = append(report.Warnings, "failed to install the CA in the system trust store")
 		}
 		m.m.ignoreCheckFailure = true
 	}
@@ -69,7 +125,11 @@ func (m *MkCert) Install() error {
 	if storeEnabled("nss") && hasNSS {
 		if !m.m.checkNSS() {
 			if hasCertutil && m.m.installNSS() {
-				fmt.Printf("The local CA is now installed in the %s trust store (requires browser restart)!\n", NSSBrowsers)
+				report.NSSInstalled = true
+				report.NSSBrowsers = NSSBrowsers
+				m.logger.Infof("The local CA is now installed in the %s trust store (requires browser restart)!", NSSBrowsers)
+			} else if !hasCertutil {
+				report.Warnings = append(report.Warnings, "certutil is not available, so the CA can't be installed in the NSS trust store")
 			}
 		}
 	}
@@ -78,12 +138,19 @@ func (m *MkCert) Install() error {
 		if !m.m.checkJava() {
 			if hasKeytool {
 				m.m.installJava()
-				fmt.Println("The local CA is now installed in Java's trust store!")
+				if m.m.checkJava() {
+					report.JavaInstalled = true
+					m.logger.Infof("The local CA is now installed in Java's trust store!")
+				} else {
+					report.Warnings = append(report.Warnings, "failed to install the CA in the Java trust store")
+				}
+			} else {
+				report.Warnings = append(report.Warnings, "keytool is not available, so the CA can't be installed in the Java trust store")
 			}
 		}
 	}
 
-	return nil
+	return report, nil
 }
 
 // Uninstall uninstalls the local CA from the system trust store.
@@ -103,37 +170,57 @@ func (m *MkCert) Uninstall() error {
 	return nil
 }
 
-// MakeCert creates a new certificate for the given hostnames.
-func (m *MkCert) MakeCert(hostnames []string, options *CertOptions) error {
+// IssueResult describes the certificate MakeCert just issued.
+type IssueResult struct {
+	CertPath string              // Where the certificate (and any chain) was written
+	KeyPath  string              // Where the private key was written
+	P12Path  string              // Where the PKCS#12 bundle was written, if requested
+	Serial   *big.Int            // The issued certificate's serial number
+	NotAfter time.Time           // The issued certificate's expiry
+	Chain    []*x509.Certificate // The issued certificate followed by any intermediates
+}
+
+// MakeCert creates a new certificate for the given hostnames. If m was
+// returned by CreateIntermediateCA, the certificate is signed by that
+// intermediate instead of the root, and the intermediate is included in the
+// output PEM bundle and PKCS#12 chain so servers present a full chain.
+func (m *MkCert) MakeCert(hostnames []string, options *CertOptions) (*IssueResult, error) {
 	if m.m.caKey == nil {
-		return fmt.Errorf("can't create new certificates because the CA key (rootCA-key.pem) is missing")
+		return nil, fmt.Errorf("can't create new certificates because the CA key (rootCA-key.pem) is missing")
 	}
 
-	if options != nil {
-		m.m.ecdsa = options.ECDSA
-		m.m.client = options.Client
-		m.m.pkcs12 = options.PKCS12
-		m.m.certFile = options.CertFile
-		m.m.keyFile = options.KeyFile
-		m.m.p12File = options.P12File
+	if m.intermediate != nil {
+		return m.makeCertWithIntermediate(hostnames, options)
 	}
 
-	m.m.makeCert(hostnames)
-	return nil
+	return m.issueLeaf(m.m.caCert, m.m.caKey, nil, hostnames, options)
 }
 
-// MakeCertFromCSR creates a new certificate from a CSR file.
+// MakeCertFromCSR creates a new certificate from a CSR file, signed with the
+// CA key (sourced from the configured KeyManager via SignCSRBytes).
 func (m *MkCert) MakeCertFromCSR(csrPath string, certFile string) error {
 	if m.m.caKey == nil {
 		return fmt.Errorf("can't create new certificates because the CA key (rootCA-key.pem) is missing")
 	}
 
-	m.m.csrPath = csrPath
-	if certFile != "" {
-		m.m.certFile = certFile
+	csrPEM, err := os.ReadFile(csrPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the CSR %q: %w", csrPath, err)
+	}
+
+	certPEM, err := m.SignCSRBytes(csrPEM, nil)
+	if err != nil {
+		return err
 	}
 
-	m.m.makeCertFromCSR()
+	if certFile == "" {
+		certFile = strings.TrimSuffix(filepath.Base(csrPath), filepath.Ext(csrPath)) + ".pem"
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to save the certificate: %w", err)
+	}
+
+	m.logger.Infof("Created a new certificate from the CSR at %s", csrPath)
 	return nil
 }
 
@@ -150,8 +237,7 @@ func (m *MkCert) CARoot() string {
 // CreateCA creates a new CA if it doesn't exist.
 // Normally this is done automatically, but this method allows explicitly creating a new CA.
 func (m *MkCert) CreateCA() error {
-	m.m.newCA()
-	return nil
+	return m.loadOrCreateRootCA()
 }
 
 // CAFiles returns the paths to the CA certificate and key files.
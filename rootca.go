@@ -0,0 +1,101 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	rootKeyID             = "rootCA"
+	defaultRootCAValidity = 10 * 365 * 24 * time.Hour
+)
+
+// loadOrCreateRootCA loads the root CA certificate and key from CAROOT,
+// generating a new self-signed root CA through mc.keyManager if none exists
+// yet. The key always comes from mc.keyManager rather than being read
+// straight off disk, so WithKeyManager lets the root key live in an HSM or
+// platform keystore instead of rootCA-key.pem.
+func (mc *MkCert) loadOrCreateRootCA() error {
+	certPath := filepath.Join(mc.m.CAROOT, rootName)
+	certPEM, err := os.ReadFile(certPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return mc.createRootCA(certPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the CA certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("failed to decode the CA certificate %q", certPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CA certificate %q: %w", certPath, err)
+	}
+
+	key, err := mc.keyManager.LoadKey(rootKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to load the CA key: %w", err)
+	}
+
+	mc.m.caCert = cert
+	mc.m.caKey = key
+	return nil
+}
+
+// createRootCA generates a new root CA key through mc.keyManager, self-signs
+// it, and writes the certificate to certPath.
+func (mc *MkCert) createRootCA(certPath string) error {
+	key, err := mc.keyManager.GenerateAndStoreKey(rootKeyID, KeyAlgoRSA)
+	if err != nil {
+		return fmt.Errorf("failed to generate the CA key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate the CA serial number: %w", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"mkcert development CA"},
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(defaultRootCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, key.Public(), key)
+	if err != nil {
+		return fmt.Errorf("failed to create the CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CA certificate: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to save the CA certificate: %w", err)
+	}
+
+	mc.m.caCert = cert
+	mc.m.caKey = key
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestLoadOrCreateRootCAGeneratesThroughKeyManager(t *testing.T) {
+	dir := t.TempDir()
+	km := newFileKeyManager(dir)
+	mc := &MkCert{m: &mkcert{CAROOT: dir}, keyManager: km, logger: stdLogger{}}
+
+	if err := mc.loadOrCreateRootCA(); err != nil {
+		t.Fatalf("loadOrCreateRootCA: %v", err)
+	}
+	if mc.m.caCert == nil || mc.m.caKey == nil {
+		t.Fatal("loadOrCreateRootCA left caCert/caKey unset")
+	}
+	if !mc.m.caCert.IsCA {
+		t.Error("generated root certificate is not a CA")
+	}
+
+	// A second MkCert over the same CAROOT must load the same CA rather than
+	// minting a new one.
+	reloaded := &MkCert{m: &mkcert{CAROOT: dir}, keyManager: newFileKeyManager(dir), logger: stdLogger{}}
+	if err := reloaded.loadOrCreateRootCA(); err != nil {
+		t.Fatalf("loadOrCreateRootCA (reload): %v", err)
+	}
+	if !reloaded.m.caCert.Equal(mc.m.caCert) {
+		t.Error("reloading the CA produced a different certificate")
+	}
+	wantKeyDER, err := x509.MarshalPKCS8PrivateKey(mc.m.caKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	gotKeyDER, err := x509.MarshalPKCS8PrivateKey(reloaded.m.caKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	if string(wantKeyDER) != string(gotKeyDER) {
+		t.Error("reloading the CA produced a different key")
+	}
+}
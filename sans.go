@@ -0,0 +1,38 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// classifySANs sorts hostnames into the x509 SAN buckets used when building
+// certificate (and CSR) templates: an IP literal becomes an IP SAN, an
+// address containing "@" becomes an email SAN, a string that looks like a
+// URI becomes a URI SAN, and everything else is treated as a DNS name. An
+// entry containing "@" or "://" that fails to parse as an address or URL is
+// dropped.
+func classifySANs(hostnames []string) (dnsNames []string, ips []net.IP, emails []string, uris []*url.URL) {
+	for _, h := range hostnames {
+		switch {
+		case net.ParseIP(h) != nil:
+			ips = append(ips, net.ParseIP(h))
+		case strings.Contains(h, "@"):
+			if addr, err := mail.ParseAddress(h); err == nil {
+				emails = append(emails, addr.Address)
+			}
+		case strings.Contains(h, "://"):
+			if u, err := url.Parse(h); err == nil {
+				uris = append(uris, u)
+			}
+		default:
+			dnsNames = append(dnsNames, h)
+		}
+	}
+	return dnsNames, ips, emails, uris
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClassifySANs(t *testing.T) {
+	dnsNames, ips, emails, uris := classifySANs([]string{
+		"example.com",
+		"127.0.0.1",
+		"jane@example.com",
+		"spiffe://example.com/service",
+	})
+
+	if got, want := dnsNames, []string{"example.com"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("dnsNames = %v, want %v", got, want)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("ips = %v, want [127.0.0.1]", ips)
+	}
+	if got, want := emails, []string{"jane@example.com"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("emails = %v, want %v", got, want)
+	}
+	if len(uris) != 1 || uris[0].String() != "spiffe://example.com/service" {
+		t.Errorf("uris = %v, want [spiffe://example.com/service]", uris)
+	}
+}
+
+func TestClassifySANsInvalidEmailIsDropped(t *testing.T) {
+	dnsNames, ips, emails, _ := classifySANs([]string{"not an@email"})
+	if len(dnsNames) != 0 || len(ips) != 0 || len(emails) != 0 {
+		t.Errorf("expected an unparseable email to be dropped, got dnsNames=%v ips=%v emails=%v", dnsNames, ips, emails)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// storeEnabled reports whether Install/Uninstall should touch store
+// ("system", "nss", or "java"). All stores are enabled by default; set
+// MKCERT_TRUST_STORES to a comma-separated subset to limit which ones
+// mkcert touches, e.g. when running somewhere with no browser installed.
+func storeEnabled(store string) bool {
+	stores := os.Getenv("MKCERT_TRUST_STORES")
+	if stores == "" {
+		return true
+	}
+	for _, s := range strings.Split(stores, ",") {
+		if strings.TrimSpace(s) == store {
+			return true
+		}
+	}
+	return false
+}
+
+// binaryExists reports whether name is available on $PATH.
+func binaryExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
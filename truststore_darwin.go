@@ -0,0 +1,36 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin
+
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+)
+
+// systemTrustKeychain is the keychain installPlatform adds the CA to (and
+// checkPlatform looks it up in), so it applies system-wide rather than only
+// for the current login session.
+const systemTrustKeychain = "/Library/Keychains/System.keychain"
+
+func (m *mkcert) checkPlatform() bool {
+	if m.ignoreCheckFailure {
+		return true
+	}
+	cmd := exec.Command("security", "find-certificate", "-c", m.caCert.Subject.CommonName, systemTrustKeychain)
+	return cmd.Run() == nil
+}
+
+func (m *mkcert) installPlatform() bool {
+	certPath := filepath.Join(m.CAROOT, rootName)
+	cmd := exec.Command("sudo", "security", "add-trusted-cert", "-d", "-r", "trustRoot",
+		"-k", systemTrustKeychain, certPath)
+	return cmd.Run() == nil
+}
+
+func (m *mkcert) uninstallPlatform() {
+	exec.Command("sudo", "security", "remove-trusted-cert", "-d", filepath.Join(m.CAROOT, rootName)).Run()
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// javaCAAlias is the keystore alias mkcert's CA is imported under, so
+// re-running install/uninstall is idempotent instead of accumulating
+// duplicate entries.
+const javaCAAlias = "mkcert-rootCA"
+
+var (
+	hasJava     bool
+	hasKeytool  bool
+	keytoolPath string
+	cacertsPath string
+)
+
+func init() {
+	javaHome := os.Getenv("JAVA_HOME")
+	if javaHome == "" {
+		return
+	}
+	hasJava = true
+
+	keytoolName := "keytool"
+	if runtime.GOOS == "windows" {
+		keytoolName = "keytool.exe"
+	}
+	if p := filepath.Join(javaHome, "bin", keytoolName); fileExists(p) {
+		keytoolPath, hasKeytool = p, true
+	}
+
+	cacertsPath = filepath.Join(javaHome, "lib", "security", "cacerts")
+	if !fileExists(cacertsPath) {
+		// Java 8 and earlier keep cacerts under jre/lib/security instead.
+		cacertsPath = filepath.Join(javaHome, "jre", "lib", "security", "cacerts")
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (m *mkcert) checkJava() bool {
+	if !hasKeytool {
+		return false
+	}
+	cmd := exec.Command(keytoolPath, "-list", "-keystore", cacertsPath, "-storepass", "changeit", "-alias", javaCAAlias)
+	return cmd.Run() == nil
+}
+
+func (m *mkcert) installJava() bool {
+	cmd := exec.Command(keytoolPath, "-importcert", "-noprompt",
+		"-keystore", cacertsPath, "-storepass", "changeit",
+		"-alias", javaCAAlias, "-file", filepath.Join(m.CAROOT, rootName))
+	return cmd.Run() == nil
+}
+
+func (m *mkcert) uninstallJava() {
+	exec.Command(keytoolPath, "-delete", "-keystore", cacertsPath, "-storepass", "changeit", "-alias", javaCAAlias).Run()
+}
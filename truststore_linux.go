@@ -0,0 +1,65 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// systemTrustFilename is where the CA certificate is copied so this
+// machine's certificate-update tool picks it up, matching whichever of the
+// common Linux trust-store tools is available.
+func (m *mkcert) systemTrustFilename() string {
+	switch {
+	case binaryExists("update-ca-trust"): // Fedora, RHEL
+		return "/etc/pki/ca-trust/source/anchors/mkcert-rootCA.pem"
+	case binaryExists("trust"): // Arch, other p11-kit-based distros
+		return "/etc/ca-certificates/trust-source/anchors/mkcert-rootCA.pem"
+	default: // Debian, Ubuntu, and most others
+		return "/usr/local/share/ca-certificates/mkcert-rootCA.crt"
+	}
+}
+
+func (m *mkcert) updateSystemTrust() error {
+	switch {
+	case binaryExists("update-ca-trust"):
+		return exec.Command("update-ca-trust", "extract").Run()
+	case binaryExists("trust"):
+		return exec.Command("trust", "extract-compat").Run()
+	default:
+		return exec.Command("update-ca-certificates").Run()
+	}
+}
+
+func (m *mkcert) checkPlatform() bool {
+	if m.ignoreCheckFailure {
+		return true
+	}
+	_, err := os.Stat(m.systemTrustFilename())
+	return err == nil
+}
+
+func (m *mkcert) installPlatform() bool {
+	cert, err := os.ReadFile(filepath.Join(m.CAROOT, rootName))
+	if err != nil {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(m.systemTrustFilename()), 0755); err != nil {
+		return false
+	}
+	if err := os.WriteFile(m.systemTrustFilename(), cert, 0644); err != nil {
+		return false
+	}
+	return m.updateSystemTrust() == nil
+}
+
+func (m *mkcert) uninstallPlatform() {
+	os.Remove(m.systemTrustFilename())
+	m.updateSystemTrust()
+}
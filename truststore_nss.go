@@ -0,0 +1,116 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// NSSBrowsers describes the browsers NSSInstalled covers, for display in an
+// InstallReport.
+const NSSBrowsers = "Firefox and/or Chrome/Chromium"
+
+var (
+	hasCertutil  bool
+	certutilPath string
+	hasNSS       bool
+)
+
+func init() {
+	switch runtime.GOOS {
+	case "darwin":
+		for _, p := range []string{"/usr/local/opt/nss/bin/certutil", "/opt/homebrew/opt/nss/bin/certutil"} {
+			if _, err := os.Stat(p); err == nil {
+				certutilPath, hasCertutil = p, true
+				break
+			}
+		}
+	}
+	if !hasCertutil {
+		if p, err := exec.LookPath("certutil"); err == nil {
+			certutilPath, hasCertutil = p, true
+		}
+	}
+	hasNSS = len(nssProfileDirs()) > 0
+}
+
+// nssProfileDirs returns the NSS certificate database directories this
+// machine's Firefox and Chrome/Chromium installs use, since neither
+// consults the OS trust store for certificate validation.
+func nssProfileDirs() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var patterns []string
+	switch runtime.GOOS {
+	case "darwin":
+		patterns = []string{
+			filepath.Join(home, "Library/Application Support/Firefox/Profiles/*"),
+			filepath.Join(home, "Library/Application Support/Google/Chrome"),
+		}
+	case "windows":
+		patterns = []string{
+			filepath.Join(os.Getenv("APPDATA"), "Mozilla/Firefox/Profiles/*"),
+		}
+	default:
+		patterns = []string{
+			filepath.Join(home, ".mozilla/firefox/*"),
+			filepath.Join(home, ".pki/nssdb"),
+		}
+	}
+
+	var dirs []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		dirs = append(dirs, matches...)
+	}
+	return dirs
+}
+
+// nssUniqueName derives a stable nickname for the CA in an NSS database from
+// its certificate, so installing/uninstalling repeatedly doesn't accumulate
+// duplicate entries.
+func (m *mkcert) nssUniqueName() string {
+	sum := sha256.Sum256(m.caCert.Raw)
+	return "mkcert-" + hex.EncodeToString(sum[:8])
+}
+
+func (m *mkcert) checkNSS() bool {
+	if !hasCertutil {
+		return false
+	}
+	for _, dir := range nssProfileDirs() {
+		cmd := exec.Command(certutilPath, "-V", "-d", "sql:"+dir, "-u", "L", "-n", m.nssUniqueName())
+		if cmd.Run() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *mkcert) installNSS() bool {
+	ok := true
+	for _, dir := range nssProfileDirs() {
+		cmd := exec.Command(certutilPath, "-A", "-d", "sql:"+dir, "-t", "C,,",
+			"-n", m.nssUniqueName(), "-i", filepath.Join(m.CAROOT, rootName))
+		if cmd.Run() != nil {
+			ok = false
+		}
+	}
+	return ok
+}
+
+func (m *mkcert) uninstallNSS() {
+	for _, dir := range nssProfileDirs() {
+		exec.Command(certutilPath, "-D", "-d", "sql:"+dir, "-n", m.nssUniqueName()).Run()
+	}
+}
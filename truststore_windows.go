@@ -0,0 +1,29 @@
+// Copyright 2023 The mkcert Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+)
+
+func (m *mkcert) checkPlatform() bool {
+	if m.ignoreCheckFailure {
+		return true
+	}
+	cmd := exec.Command("certutil", "-verifystore", "-user", "ROOT", m.caCert.SerialNumber.Text(16))
+	return cmd.Run() == nil
+}
+
+func (m *mkcert) installPlatform() bool {
+	cmd := exec.Command("certutil", "-addstore", "-user", "ROOT", filepath.Join(m.CAROOT, rootName))
+	return cmd.Run() == nil
+}
+
+func (m *mkcert) uninstallPlatform() {
+	exec.Command("certutil", "-delstore", "-user", "ROOT", m.caCert.SerialNumber.Text(16)).Run()
+}